@@ -0,0 +1,104 @@
+package matcher
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestArrayUnorderedMarker(t *testing.T) {
+	pattern := `["#array-unordered", ["#number > 2", "#number < 2", "#string"]]`
+
+	conflicts, err := JSONStringMatches(`[1, 3, "x"]`, pattern)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(conflicts) != 0 {
+		t.Fatalf("expected no conflicts, got %v", conflicts)
+	}
+
+	conflicts, err = JSONStringMatches(`[1, 3]`, pattern)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(conflicts) != 1 {
+		t.Fatalf("expected a length mismatch conflict, got %v", conflicts)
+	}
+
+	conflicts, err = JSONStringMatches(`[1, 2, "x"]`, pattern)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(conflicts) != 1 {
+		t.Fatalf("expected no bijection to be found, got %v", conflicts)
+	}
+}
+
+func TestArrayContainsMarker(t *testing.T) {
+	conflicts, err := JSONStringMatches(`[1, 2, 3]`, `["#array-contains", "#number > 2"]`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(conflicts) != 0 {
+		t.Fatalf("expected no conflicts, got %v", conflicts)
+	}
+
+	conflicts, err = JSONStringMatches(`[1, 2]`, `["#array-contains", "#number > 2"]`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(conflicts) != 1 {
+		t.Fatalf("expected a conflict when no element matches, got %v", conflicts)
+	}
+}
+
+func TestArraySubsetMarker(t *testing.T) {
+	conflicts, err := JSONStringMatches(`[1, 2, 3]`, `["#array-subset", ["#number > 2", "#number < 2"]]`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(conflicts) != 0 {
+		t.Fatalf("expected no conflicts, got %v", conflicts)
+	}
+
+	conflicts, err = JSONStringMatches(`[1, 2, 3]`, `["#array-subset", ["#number > 2", "#number > 100"]]`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(conflicts) != 1 {
+		t.Fatalf("expected a conflict for the unmatched spec, got %v", conflicts)
+	}
+}
+
+func TestArrayDistinctMarker(t *testing.T) {
+	conflicts, err := JSONStringMatches(`[1, 2, 3]`, `["#array-distinct"]`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(conflicts) != 0 {
+		t.Fatalf("expected no conflicts, got %v", conflicts)
+	}
+
+	conflicts, err = JSONStringMatches(`[1, 2, 2]`, `["#array-distinct"]`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(conflicts) != 1 {
+		t.Fatalf("expected a conflict for duplicate elements, got %v", conflicts)
+	}
+}
+
+func TestArrayUnorderedRejectsOversizedInput(t *testing.T) {
+	specs := make([]string, maxUnorderedElements+1)
+	elems := make([]string, maxUnorderedElements+1)
+	for i := range specs {
+		specs[i] = `"#number"`
+		elems[i] = "1"
+	}
+	pattern := `["#array-unordered", [` + strings.Join(specs, ",") + `]]`
+	doc := `[` + strings.Join(elems, ",") + `]`
+
+	_, err := JSONStringMatches(doc, pattern)
+	if err == nil {
+		t.Fatal("expected an error once the element count exceeds the backtracking limit")
+	}
+}
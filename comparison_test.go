@@ -0,0 +1,82 @@
+package matcher
+
+import "testing"
+
+func TestNumberComparisonMarkers(t *testing.T) {
+	tests := []struct {
+		name    string
+		json    string
+		pattern string
+		wantOK  bool
+	}{
+		{"gte", `{"n": 5}`, `{"n": "#number >= 5"}`, true},
+		{"gte_fails", `{"n": 4}`, `{"n": "#number >= 5"}`, false},
+		{"range_inclusive", `{"n": 10}`, `{"n": "#number in [1,10]"}`, true},
+		{"range_fails_outside_bounds", `{"n": 11}`, `{"n": "#number in [1,10]"}`, false},
+		{"set_membership", `{"n": 3}`, `{"n": "#number in {1,2,3}"}`, true},
+		{"set_membership_fails", `{"n": 4}`, `{"n": "#number in {1,2,3}"}`, false},
+		{"negative_number", `{"n": -5}`, `{"n": "#number < 0"}`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conflicts, err := JSONStringMatches(tt.json, tt.pattern)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if ok := len(conflicts) == 0; ok != tt.wantOK {
+				t.Fatalf("got conflicts=%v, want ok=%v", conflicts, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestStringComparisonMarkers(t *testing.T) {
+	tests := []struct {
+		name    string
+		json    string
+		pattern string
+		wantOK  bool
+	}{
+		{"length_gt", `{"s": "hello"}`, `{"s": "#string length > 3"}`, true},
+		{"length_gt_fails", `{"s": "hi"}`, `{"s": "#string length > 3"}`, false},
+		{"starts_with", `{"s": "hello world"}`, `{"s": "#string startsWith \"hello\""}`, true},
+		{"ends_with", `{"s": "hello world"}`, `{"s": "#string endsWith \"world\""}`, true},
+		{"ends_with_fails", `{"s": "hello world"}`, `{"s": "#string endsWith \"hello\""}`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conflicts, err := JSONStringMatches(tt.json, tt.pattern)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if ok := len(conflicts) == 0; ok != tt.wantOK {
+				t.Fatalf("got conflicts=%v, want ok=%v", conflicts, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestArrayLengthComparisonMarker(t *testing.T) {
+	conflicts, err := JSONStringMatches(`{"items": [1,2,3]}`, `{"items": "#array length == 3"}`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(conflicts) != 0 {
+		t.Fatalf("expected no conflicts, got %v", conflicts)
+	}
+
+	conflicts, err = JSONStringMatches(`{"items": []}`, `{"items": "#array length == 3"}`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(conflicts) != 1 {
+		t.Fatalf("expected a conflict for an empty array, got %v", conflicts)
+	}
+}
+
+func TestInvalidComparisonReturnsError(t *testing.T) {
+	_, err := JSONStringMatches(`{"n": 1}`, `{"n": "#number not-a-comparison"}`)
+	if err == nil {
+		t.Fatal("expected an error for a malformed #number comparison")
+	}
+}
@@ -0,0 +1,595 @@
+package matcher
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/vm"
+)
+
+// nodeKind discriminates the shape a compiledNode was built from, so that
+// _matchCompiledAny can dispatch without re-inspecting the original spec.
+type nodeKind int
+
+const (
+	nodeValue nodeKind = iota
+	nodeMarker
+	nodeMap
+	nodeSlice
+	nodeArrayOf
+	nodeSetMarker
+)
+
+// compiledNode is a pattern spec node that has already been walked and
+// type-switched once: marker strings are pre-split and their #regex/#expr/
+// comparison/quantifier arguments pre-parsed, map/array children are
+// pre-resolved into child nodes, and the "#array-of"/set-marker array shapes
+// are pre-recognized. Matching many documents against the same CompiledPattern
+// then descends this tree directly instead of re-running getMarker,
+// strings.SplitN, the comparison tokenizer, and regexp/expr compilation on
+// every call.
+type compiledNode struct {
+	kind     nodeKind
+	original interface{} // the raw spec value, kept for Conflict.Expected
+
+	literal interface{}     // nodeValue
+	marker  *compiledMarker // nodeMarker
+
+	mapChildren map[string]*compiledNode // nodeMap
+
+	sliceChildren []*compiledNode // nodeSlice
+
+	arrayOf *compiledNode // nodeArrayOf
+
+	setKind     string          // nodeSetMarker: "#array-unordered" etc.
+	setChildren []*compiledNode // nodeSetMarker: the marker's spec operand(s)
+}
+
+// compiledMarker is a "#..." marker string that has been split and had its
+// argument (regex/expr/comparison/quantifier) pre-compiled, where doing so is
+// possible without the value being matched. Markers with no argument worth
+// pre-parsing (#ignore, #uuid, #ref, ...) carry only head/arg/raw and are
+// matched by delegating to _matchWithMarker, same as the uncompiled path.
+type compiledMarker struct {
+	raw  string
+	head string
+	arg  string
+
+	isQuantifier  bool
+	quantifierOp  string
+	quantifierNum int
+
+	comparison *comparison
+	regex      *regexp.Regexp
+	program    *vm.Program
+}
+
+// _compileNode walks spec once, producing a compiledNode tree and surfacing
+// any #regex/#expr/comparison argument error at compile time instead of on
+// first match.
+func _compileNode(spec interface{}) (*compiledNode, error) {
+	if specStr, ok := spec.(string); ok {
+		if isMarker, marker := getMarker(specStr); isMarker {
+			cm, err := _compileMarkerNode(marker)
+			if err != nil {
+				return nil, err
+			}
+			return &compiledNode{kind: nodeMarker, marker: cm, original: spec}, nil
+		}
+		return &compiledNode{kind: nodeValue, literal: spec, original: spec}, nil
+	}
+
+	switch v := spec.(type) {
+	case map[string]interface{}:
+		children := make(map[string]*compiledNode, len(v))
+		for key, val := range v {
+			child, err := _compileNode(val)
+			if err != nil {
+				return nil, err
+			}
+			children[key] = child
+		}
+		return &compiledNode{kind: nodeMap, mapChildren: children, original: spec}, nil
+	case []interface{}:
+		return _compileSliceNode(v)
+	default:
+		return &compiledNode{kind: nodeValue, literal: spec, original: spec}, nil
+	}
+}
+
+// setMarkerArities mirrors the shapes _matchSliceSetMarker recognizes: a
+// two-element `[marker, specOrSpecs]` array for all but "#array-distinct",
+// which is the one-element `[marker]`.
+//
+//nolint:gochecknoglobals // a fixed lookup table, not mutated after init
+var setMarkerArities = map[string]int{
+	"#array-unordered": 2,
+	"#array-contains":  2,
+	"#array-subset":    2,
+	"#array-distinct":  1,
+}
+
+func _compileSliceNode(v []interface{}) (*compiledNode, error) {
+	if len(v) > 0 {
+		if isMarker, marker := getMarker(v[0]); isMarker {
+			if arity, known := setMarkerArities[marker]; known && len(v) == arity {
+				return _compileSetMarkerNode(marker, v)
+			}
+		}
+	}
+
+	//nolint:gomnd // the "magic" literal constant 2 here is clearer than a synthetic constant symbol
+	if len(v) == 2 {
+		if isMarker, marker := getMarker(v[0]); isMarker && marker == "#array-of" {
+			child, err := _compileNode(v[1])
+			if err != nil {
+				return nil, err
+			}
+			return &compiledNode{kind: nodeArrayOf, arrayOf: child, original: v}, nil
+		}
+	}
+
+	children := make([]*compiledNode, len(v))
+	for i, val := range v {
+		child, err := _compileNode(val)
+		if err != nil {
+			return nil, err
+		}
+		children[i] = child
+	}
+	return &compiledNode{kind: nodeSlice, sliceChildren: children, original: v}, nil
+}
+
+func _compileSetMarkerNode(marker string, v []interface{}) (*compiledNode, error) {
+	node := &compiledNode{kind: nodeSetMarker, setKind: marker, original: v}
+	if marker == "#array-distinct" {
+		return node, nil
+	}
+	if marker == "#array-contains" {
+		child, err := _compileNode(v[1])
+		if err != nil {
+			return nil, err
+		}
+		node.setChildren = []*compiledNode{child}
+		return node, nil
+	}
+	specs, ok := v[1].([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("expected an array of specs for %s", marker)
+	}
+	children := make([]*compiledNode, len(specs))
+	for i, s := range specs {
+		child, err := _compileNode(s)
+		if err != nil {
+			return nil, err
+		}
+		children[i] = child
+	}
+	node.setChildren = children
+	return node, nil
+}
+
+// _compileMarkerNode splits a "#..." marker and pre-compiles the argument of
+// #regex/#expr/#[num] EXPR/#number/#string/#array, so that _matchCompiledMarker
+// never re-tokenizes or re-compiles it. Markers without such an argument are
+// left for _matchWithMarker to handle at match time, same as the uncompiled path.
+func _compileMarkerNode(raw string) (*compiledMarker, error) {
+	//nolint:gomnd // the "magic" literal constant 2 here is clearer than a synthetic constant symbol
+	parts := strings.SplitN(raw, " ", 2)
+	cm := &compiledMarker{raw: raw, head: parts[0]}
+	if len(parts) == 2 {
+		cm.arg = parts[1]
+	}
+
+	if quantifier := arrayQuantifierRe.FindStringSubmatch(cm.head); quantifier != nil {
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("expected an expression argument for '%s'", cm.head)
+		}
+		program, err := compileExprCached(cm.arg)
+		if err != nil {
+			return nil, fmt.Errorf("invalid expression argument to '%s': %w", cm.head, err)
+		}
+		num, err := strconv.Atoi(quantifier[2])
+		if err != nil {
+			return nil, fmt.Errorf("invalid count in '%s': %w", cm.head, err)
+		}
+		cm.isQuantifier = true
+		cm.quantifierOp = quantifier[1]
+		cm.quantifierNum = num
+		cm.program = program
+		return cm, nil
+	}
+
+	switch cm.head {
+	case "#array":
+		if len(parts) == 2 {
+			cmp, err := _parseArrayComparison(cm.arg)
+			if err != nil {
+				return nil, fmt.Errorf("invalid comparison argument to #array: %w", err)
+			}
+			cm.comparison = &cmp
+		}
+	case "#number":
+		if len(parts) == 2 {
+			cmp, err := _parseNumberComparison(cm.arg)
+			if err != nil {
+				return nil, fmt.Errorf("invalid comparison argument to #number: %w", err)
+			}
+			cm.comparison = &cmp
+		}
+	case "#string":
+		if len(parts) == 2 {
+			cmp, err := _parseStringComparison(cm.arg)
+			if err != nil {
+				return nil, fmt.Errorf("invalid comparison argument to #string: %w", err)
+			}
+			cm.comparison = &cmp
+		}
+	case "#regex":
+		//nolint:gomnd // the "magic" literal constant 2 here is clearer than a synthetic constant symbol
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("expected exactly one argument for #regex")
+		}
+		r, err := compileRegexCached(cm.arg)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex argument to #regex: %w", err)
+		}
+		cm.regex = r
+	case "#expr":
+		//nolint:gomnd // the "magic" literal constant 2 here is clearer than a synthetic constant symbol
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("expected exactly one argument for #expr")
+		}
+		program, err := compileExprCached(cm.arg)
+		if err != nil {
+			return nil, fmt.Errorf("invalid expression argument to #expr: %w", err)
+		}
+		cm.program = program
+	}
+	return cm, nil
+}
+
+// _matchCompiledAny is the compiled-tree counterpart of _match: it descends
+// node instead of re-walking/re-type-switching spec on every call.
+func _matchCompiledAny(ctx matchCtx, x interface{}, node *compiledNode) ([]Conflict, error) {
+	switch node.kind {
+	case nodeMarker:
+		return _matchCompiledMarker(ctx, x, node.marker, node.original)
+	case nodeMap:
+		return _matchCompiledMap(ctx, x, node)
+	case nodeSlice, nodeArrayOf, nodeSetMarker:
+		return _matchCompiledSlice(ctx, x, node)
+	default:
+		return _matchCompiledValue(ctx, x, node)
+	}
+}
+
+func _matchCompiledValue(ctx matchCtx, x interface{}, node *compiledNode) ([]Conflict, error) {
+	possibleConflict := Conflict{Path: ctx.path, Expected: node.original, Actual: x}
+	if node.literal == nil {
+		return _matchZero(ctx, x), nil
+	}
+	xV := reflect.ValueOf(x)
+	if !xV.IsValid() {
+		return []Conflict{possibleConflict}, nil
+	}
+	if xV.Kind() != reflect.ValueOf(node.literal).Kind() {
+		return []Conflict{possibleConflict}, nil
+	}
+	if !reflect.DeepEqual(x, node.literal) {
+		return []Conflict{possibleConflict}, nil
+	}
+	return []Conflict{}, nil
+}
+
+// _matchCompiledMarker dispatches to the precompiled fast path for the
+// marker kinds whose argument was pre-parsed by _compileMarkerNode, falling
+// back to _matchWithMarker (which re-splits/re-parses, as before) for every
+// other marker — those carry no argument worth precompiling in the first
+// place, so there is nothing to gain from a dedicated path.
+func _matchCompiledMarker(ctx matchCtx, x interface{}, cm *compiledMarker, original interface{}) ([]Conflict, error) {
+	switch {
+	case cm.regex != nil:
+		return _matchPrecompiledRegex(ctx, x, cm, original)
+	case cm.isQuantifier:
+		return _matchPrecompiledQuantifier(ctx, x, cm, original)
+	case cm.head == "#expr" && cm.program != nil:
+		return _matchPrecompiledExpr(ctx, x, cm, original)
+	case cm.comparison != nil:
+		return _matchPrecompiledComparison(ctx, x, cm, original)
+	default:
+		return _matchWithMarker(ctx, x, cm.raw)
+	}
+}
+
+func _matchPrecompiledRegex(ctx matchCtx, x interface{}, cm *compiledMarker, original interface{}) ([]Conflict, error) {
+	possibleConflict := Conflict{Path: ctx.path, Expected: original, Actual: x}
+	xV := reflect.ValueOf(x)
+	if !xV.IsValid() || xV.Kind() != reflect.String {
+		return []Conflict{possibleConflict}, nil
+	}
+	xString, ok := x.(string)
+	if ok && cm.regex.MatchString(xString) {
+		return []Conflict{}, nil
+	}
+	return []Conflict{possibleConflict}, nil
+}
+
+func _matchPrecompiledExpr(ctx matchCtx, x interface{}, cm *compiledMarker, original interface{}) ([]Conflict, error) {
+	possibleConflict := Conflict{Path: ctx.path, Expected: original, Actual: x}
+	xV := reflect.ValueOf(x)
+	if !xV.IsValid() {
+		return []Conflict{possibleConflict}, nil
+	}
+	result, err := expr.Run(cm.program, exprEnv(x, ctx.options))
+	if err != nil {
+		return []Conflict{possibleConflict}, fmt.Errorf("can't evaluate #expr: %w", err)
+	}
+	if matched, ok := result.(bool); ok && matched {
+		return []Conflict{}, nil
+	}
+	return []Conflict{possibleConflict}, nil
+}
+
+func _matchPrecompiledQuantifier(ctx matchCtx, x interface{}, cm *compiledMarker, original interface{}) ([]Conflict, error) {
+	possibleConflict := Conflict{Path: ctx.path, Expected: original, Actual: x}
+	xV := reflect.ValueOf(x)
+	if !xV.IsValid() || (xV.Kind() != reflect.Slice && xV.Kind() != reflect.Array) {
+		return []Conflict{possibleConflict}, nil
+	}
+
+	var failedIndices []int
+	matchCount := 0
+	for i := 0; i < xV.Len(); i++ {
+		env := exprEnv(xV.Index(i).Interface(), ctx.options)
+		env["i"] = i
+		result, err := expr.Run(cm.program, env)
+		if err != nil {
+			return []Conflict{possibleConflict}, fmt.Errorf("can't evaluate expression for element %d: %w", i, err)
+		}
+		if matched, ok := result.(bool); ok && matched {
+			matchCount++
+		} else {
+			failedIndices = append(failedIndices, i)
+		}
+	}
+
+	if _quantifierSatisfied(cm.quantifierOp, cm.quantifierNum, matchCount) {
+		return []Conflict{}, nil
+	}
+	conflict := possibleConflict
+	conflict.Expected = fmt.Sprintf("%s %s (failed indices: %v)", cm.head, cm.arg, failedIndices)
+	return []Conflict{conflict}, nil
+}
+
+func _matchPrecompiledComparison(ctx matchCtx, x interface{}, cm *compiledMarker, original interface{}) ([]Conflict, error) {
+	possibleConflict := Conflict{Path: ctx.path, Expected: original, Actual: x}
+	xV := reflect.ValueOf(x)
+	if !xV.IsValid() {
+		return []Conflict{possibleConflict}, nil
+	}
+	switch cm.head {
+	case "#number":
+		if xV.Kind() != reflect.Int64 && xV.Kind() != reflect.Float64 {
+			return []Conflict{possibleConflict}, nil
+		}
+		xNum, ok := _asFloat64(x)
+		if !ok || !cm.comparison.matchesNumber(xNum) {
+			return []Conflict{possibleConflict}, nil
+		}
+	case "#string":
+		if xV.Kind() != reflect.String {
+			return []Conflict{possibleConflict}, nil
+		}
+		xString, ok := x.(string)
+		if !ok || !cm.comparison.matchesString(xString) {
+			return []Conflict{possibleConflict}, nil
+		}
+	case "#array":
+		if xV.Kind() != reflect.Array && xV.Kind() != reflect.Slice {
+			return []Conflict{possibleConflict}, nil
+		}
+		if !cm.comparison.matchesLength(xV.Len()) {
+			return []Conflict{possibleConflict}, nil
+		}
+	}
+	return []Conflict{}, nil
+}
+
+func _matchCompiledMap(ctx matchCtx, x interface{}, node *compiledNode) ([]Conflict, error) {
+	possibleConflict := Conflict{Path: ctx.path, Expected: node.original, Actual: x}
+	vX, ok := x.(map[string]interface{})
+	if !ok {
+		return []Conflict{possibleConflict}, nil
+	}
+
+	childCtx := ctx.pushed(x)
+
+	var conflicts []Conflict
+	for key, xVal := range vX {
+		childNode, ok := node.mapChildren[key]
+		if !ok {
+			continue // missing spec for this key, skip...
+		}
+		itemConflicts, err := _matchCompiledAny(childCtx.withPath(ctx.path+"/"+key), xVal, childNode)
+		conflicts = append(conflicts, itemConflicts...)
+		if err != nil {
+			return conflicts, fmt.Errorf("can't compare map element %s/%s: %w", ctx.path, key, err)
+		}
+	}
+	if len(conflicts) > 0 {
+		return conflicts, nil
+	}
+
+	for key, childNode := range node.mapChildren {
+		xVal, present := vX[key]
+		possibleChildConflict := Conflict{
+			Path:     ctx.path + "/" + key,
+			Expected: childNode.original,
+			Actual:   fmt.Sprint(xVal),
+		}
+
+		if childNode.kind == nodeMarker {
+			switch childNode.marker.raw {
+			case "#notpresent":
+				if present {
+					conflicts = append(conflicts, possibleChildConflict)
+				}
+				continue
+			case presentMarker:
+				if !present {
+					conflicts = append(conflicts, possibleChildConflict)
+				}
+				continue
+			case ignoreMarker:
+				continue
+			}
+		}
+
+		if !present {
+			conflicts = append(conflicts, possibleChildConflict)
+			continue
+		}
+		itemConflicts, err := _matchCompiledAny(childCtx.withPath(ctx.path+"/"+key), xVal, childNode)
+		conflicts = append(conflicts, itemConflicts...)
+		if err != nil {
+			return conflicts, fmt.Errorf("can't compare map element %s: %w", key, err)
+		}
+	}
+	return conflicts, nil
+}
+
+func _matchCompiledSlice(ctx matchCtx, x interface{}, node *compiledNode) ([]Conflict, error) {
+	possibleConflict := Conflict{Path: ctx.path, Expected: node.original, Actual: x}
+	vX := reflect.ValueOf(x)
+	if vX.Kind() != reflect.Slice && vX.Kind() != reflect.Array {
+		return []Conflict{possibleConflict}, nil
+	}
+
+	childCtx := ctx.pushed(x)
+
+	switch node.kind {
+	case nodeSetMarker:
+		return _matchCompiledSetMarker(childCtx, vX, node, possibleConflict)
+	case nodeArrayOf:
+		var conflicts []Conflict
+		for i := 0; i < vX.Len(); i++ {
+			itemConflicts, err := _matchCompiledAny(childCtx.withPath(ctx.path+"["+fmt.Sprint(i)+"]"), vX.Index(i).Interface(), node.arrayOf)
+			conflicts = append(conflicts, itemConflicts...)
+			if err != nil {
+				return conflicts, fmt.Errorf("can't compare slice element %v: %w", i, err)
+			}
+		}
+		return conflicts, nil
+	default:
+		if vX.Len() != len(node.sliceChildren) {
+			return []Conflict{possibleConflict}, nil
+		}
+		var conflicts []Conflict
+		for i, childNode := range node.sliceChildren {
+			itemConflicts, err := _matchCompiledAny(childCtx.withPath(ctx.path+"["+fmt.Sprint(i)+"]"), vX.Index(i).Interface(), childNode)
+			conflicts = append(conflicts, itemConflicts...)
+			if err != nil {
+				return conflicts, fmt.Errorf("can't compare slice element %v: %w", i, err)
+			}
+		}
+		return conflicts, nil
+	}
+}
+
+func _matchCompiledSetMarker(ctx matchCtx, vX reflect.Value, node *compiledNode, possibleConflict Conflict) ([]Conflict, error) {
+	switch node.setKind {
+	case "#array-unordered":
+		return _matchCompiledArrayUnordered(ctx, vX, node.setChildren, possibleConflict)
+	case "#array-contains":
+		for i := 0; i < vX.Len(); i++ {
+			ok, err := _compiledSpecMatchesElement(ctx, vX.Index(i).Interface(), node.setChildren[0])
+			if err != nil {
+				return []Conflict{possibleConflict}, err
+			}
+			if ok {
+				return []Conflict{}, nil
+			}
+		}
+		return []Conflict{possibleConflict}, nil
+	case "#array-subset":
+		var unmatched []int
+		for specIdx, childNode := range node.setChildren {
+			found := false
+			for i := 0; i < vX.Len() && !found; i++ {
+				ok, err := _compiledSpecMatchesElement(ctx, vX.Index(i).Interface(), childNode)
+				if err != nil {
+					return []Conflict{possibleConflict}, err
+				}
+				found = ok
+			}
+			if !found {
+				unmatched = append(unmatched, specIdx)
+			}
+		}
+		if len(unmatched) == 0 {
+			return []Conflict{}, nil
+		}
+		conflict := possibleConflict
+		conflict.Expected = fmt.Sprintf("#array-subset: unmatched spec indices %v", unmatched)
+		return []Conflict{conflict}, nil
+	case "#array-distinct":
+		for i := 0; i < vX.Len(); i++ {
+			for j := i + 1; j < vX.Len(); j++ {
+				if reflect.DeepEqual(vX.Index(i).Interface(), vX.Index(j).Interface()) {
+					conflict := possibleConflict
+					conflict.Expected = fmt.Sprintf("#array-distinct: elements %d and %d are equal", i, j)
+					return []Conflict{conflict}, nil
+				}
+			}
+		}
+		return []Conflict{}, nil
+	default:
+		return []Conflict{possibleConflict}, fmt.Errorf("unsupported set marker '%s'", node.setKind)
+	}
+}
+
+func _compiledSpecMatchesElement(ctx matchCtx, elem interface{}, node *compiledNode) (bool, error) {
+	conflicts, err := _matchCompiledAny(ctx, elem, node)
+	if err != nil {
+		return false, err
+	}
+	return len(conflicts) == 0, nil
+}
+
+// _matchCompiledArrayUnordered mirrors _matchArrayUnordered, but probes
+// element/spec compatibility via the precompiled child nodes instead of
+// re-walking the raw specs.
+func _matchCompiledArrayUnordered(ctx matchCtx, vX reflect.Value, specs []*compiledNode, possibleConflict Conflict) ([]Conflict, error) {
+	if vX.Len() != len(specs) {
+		return []Conflict{possibleConflict}, nil
+	}
+	if vX.Len() > maxUnorderedElements {
+		return []Conflict{possibleConflict}, fmt.Errorf(
+			"#array-unordered: %d elements exceeds the %d-element limit for backtracking matching",
+			vX.Len(), maxUnorderedElements,
+		)
+	}
+	compat := make([][]bool, vX.Len())
+	for i := range compat {
+		compat[i] = make([]bool, len(specs))
+		for j, spec := range specs {
+			ok, err := _compiledSpecMatchesElement(ctx, vX.Index(i).Interface(), spec)
+			if err != nil {
+				return []Conflict{possibleConflict}, err
+			}
+			compat[i][j] = ok
+		}
+	}
+	usedSpecs := make([]bool, len(specs))
+	if _bijectionExists(compat, usedSpecs, 0) {
+		return []Conflict{}, nil
+	}
+	conflict := possibleConflict
+	conflict.Expected = "#array-unordered: no bijection between elements and specs"
+	return []Conflict{conflict}, nil
+}
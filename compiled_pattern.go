@@ -0,0 +1,67 @@
+package matcher
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// CompiledPattern is a pattern spec that has been walked once into a tree of
+// compiledNode values (see compiled_node.go): every #regex/#expr/#[num] EXPR/
+// comparison argument is pre-parsed and every map/array shape pre-resolved,
+// so repeated Match/MatchAny calls descend the tree directly instead of
+// re-running json.Unmarshal, getMarker, the comparison tokenizer, and
+// regexp/expr compilation on every call. A malformed #regex/#expr/comparison
+// argument is rejected at Compile time rather than on first use.
+type CompiledPattern struct {
+	root    *compiledNode
+	options MatchOptions
+}
+
+// Compile parses jPatternSpecifier and compiles it into a reusable
+// CompiledPattern.
+func Compile(jPatternSpecifier []byte) (*CompiledPattern, error) {
+	return CompileWithOptions(jPatternSpecifier, MatchOptions{})
+}
+
+// CompileWithOptions behaves like Compile, but binds the given MatchOptions
+// to the compiled pattern; they are passed to every subsequent Match/MatchAny
+// call.
+func CompileWithOptions(jPatternSpecifier []byte, options MatchOptions) (*CompiledPattern, error) {
+	var spec interface{}
+	if err := json.Unmarshal(jPatternSpecifier, &spec); err != nil {
+		return nil, fmt.Errorf("can't unmarshal pattern argument: %w", err)
+	}
+	root, err := _compileNode(spec)
+	if err != nil {
+		return nil, err
+	}
+	return &CompiledPattern{root: root, options: options}, nil
+}
+
+// Match checks if the JSON document in j satisfies the compiled pattern.
+func (p *CompiledPattern) Match(j []byte) (Conflicts, error) {
+	var jAny interface{}
+	if err := json.Unmarshal(j, &jAny); err != nil {
+		return Conflicts{{
+			Path:  "/",
+			Error: err,
+		}}, fmt.Errorf("can't unmarshal left argument: %w", err)
+	}
+	return p.MatchAny(jAny)
+}
+
+// MatchAny behaves like Match, but takes an already-unmarshaled value,
+// skipping the JSON decode step entirely.
+func (p *CompiledPattern) MatchAny(j interface{}) (Conflicts, error) {
+	conflicts, err := _matchCompiledAny(newMatchCtx(j, p.options), j, p.root)
+	var resultingConflicts Conflicts
+	for _, c := range conflicts {
+		if strings.HasPrefix(c.Path, "//") {
+			c.Path = c.Path[1:]
+		}
+		c.root = j
+		resultingConflicts = append(resultingConflicts, c)
+	}
+	return resultingConflicts, err
+}
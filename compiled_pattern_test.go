@@ -0,0 +1,97 @@
+package matcher
+
+import "testing"
+
+func TestCompiledPatternMatch(t *testing.T) {
+	pattern, err := Compile([]byte(`{"name": "#string", "age": "#number > 0"}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	conflicts, err := pattern.Match([]byte(`{"name": "Ada", "age": 36}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(conflicts) != 0 {
+		t.Fatalf("expected no conflicts, got %v", conflicts)
+	}
+
+	conflicts, err = pattern.Match([]byte(`{"name": "Ada", "age": -1}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(conflicts) != 1 {
+		t.Fatalf("expected exactly one conflict, got %v", conflicts)
+	}
+}
+
+func TestCompileRejectsInvalidRegex(t *testing.T) {
+	_, err := Compile([]byte(`{"x": "#regex ("}`))
+	if err == nil {
+		t.Fatal("expected an error for an invalid #regex marker")
+	}
+}
+
+func TestCompileRejectsInvalidExpr(t *testing.T) {
+	_, err := Compile([]byte(`{"x": "#expr (("}`))
+	if err == nil {
+		t.Fatal("expected an error for an invalid #expr marker")
+	}
+}
+
+func TestCompiledPatternMatchAnySkipsUnmarshal(t *testing.T) {
+	pattern, err := Compile([]byte(`["#array-of", "#number"]`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	conflicts, err := pattern.MatchAny([]interface{}{1.0, 2.0, 3.0})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(conflicts) != 0 {
+		t.Fatalf("expected no conflicts, got %v", conflicts)
+	}
+}
+
+func TestCompiledPatternSetMarkersAndPresence(t *testing.T) {
+	pattern, err := Compile([]byte(`{
+		"id": "#string",
+		"extra": "#notpresent",
+		"tags": ["#array-unordered", ["#number > 2", "#number < 2"]]
+	}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	conflicts, err := pattern.Match([]byte(`{"id": "a1", "tags": [1, 3]}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(conflicts) != 0 {
+		t.Fatalf("expected no conflicts, got %v", conflicts)
+	}
+
+	conflicts, err = pattern.Match([]byte(`{"id": "a1", "extra": "oops", "tags": [1, 3]}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(conflicts) != 1 {
+		t.Fatalf("expected a conflict for the unexpected 'extra' key, got %v", conflicts)
+	}
+}
+
+func TestCompiledPatternReusedAcrossCalls(t *testing.T) {
+	pattern, err := Compile([]byte(`{"n": "#number > 0"}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for i, doc := range []string{`{"n": 1}`, `{"n": 2}`, `{"n": 3}`} {
+		conflicts, err := pattern.Match([]byte(doc))
+		if err != nil {
+			t.Fatalf("unexpected error on call %d: %v", i, err)
+		}
+		if len(conflicts) != 0 {
+			t.Fatalf("expected no conflicts on call %d, got %v", i, conflicts)
+		}
+	}
+}
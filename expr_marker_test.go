@@ -0,0 +1,81 @@
+package matcher
+
+import "testing"
+
+func TestExprMarker(t *testing.T) {
+	tests := []struct {
+		name    string
+		json    string
+		pattern string
+		wantOK  bool
+	}{
+		{"passes_simple_range", `{"n": 42}`, `{"n": "#expr _ > 0 && _ < 100"}`, true},
+		{"fails_out_of_range", `{"n": 200}`, `{"n": "#expr _ > 0 && _ < 100"}`, false},
+		{"binds_object_keys", `{"obj": {"a": 1, "b": 2}}`, `{"obj": "#expr a + b == 3.0"}`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conflicts, err := JSONStringMatches(tt.json, tt.pattern)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if ok := len(conflicts) == 0; ok != tt.wantOK {
+				t.Fatalf("got conflicts=%v, want ok=%v", conflicts, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestArrayQuantifierMarker(t *testing.T) {
+	tests := []struct {
+		name    string
+		json    string
+		pattern string
+		wantOK  bool
+	}{
+		{"exact_count", `{"items": [1, 2, -1, 3]}`, `{"items": "#[3] _ > 0"}`, true},
+		{"exact_count_fails", `{"items": [1, -2, -1, 3]}`, `{"items": "#[3] _ > 0"}`, false},
+		{"gte_count", `{"items": [1, 2, 3]}`, `{"items": "#[>=2] _ > 1"}`, true},
+		{"uses_index", `{"items": [10, 20, 30]}`, `{"items": "#[1] i == 0 && _ == 10.0"}`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conflicts, err := JSONStringMatches(tt.json, tt.pattern)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if ok := len(conflicts) == 0; ok != tt.wantOK {
+				t.Fatalf("got conflicts=%v, want ok=%v", conflicts, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestMatchOptionsFunctionsAndVariables(t *testing.T) {
+	options := MatchOptions{
+		Functions: map[string]interface{}{
+			"double": func(n float64) float64 { return n * 2 },
+		},
+		Variables: map[string]interface{}{
+			"limit": float64(10),
+		},
+	}
+	conflicts, err := JSONMatchesWithOptions(
+		[]byte(`{"n": 4}`),
+		[]byte(`{"n": "#expr double(_) == 8.0 && _ < limit"}`),
+		options,
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(conflicts) != 0 {
+		t.Fatalf("expected no conflicts, got %v", conflicts)
+	}
+}
+
+func TestInvalidExprMarkerReturnsError(t *testing.T) {
+	_, err := JSONStringMatches(`{"n": 1}`, `{"n": "#expr (("}`)
+	if err == nil {
+		t.Fatal("expected an error for a malformed #expr marker")
+	}
+}
@@ -5,8 +5,13 @@ import (
 	"fmt"
 	"reflect"
 	"regexp"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
+
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/vm"
 )
 
 type Conflict struct {
@@ -14,9 +19,57 @@ type Conflict struct {
 	Expected interface{} `json:"expected,omitempty"`
 	Actual   interface{} `json:"actual,omitempty"`
 	Error    error       `json:"error,omitempty"`
+
+	// root is the top-level actual document this conflict was found in,
+	// stamped on by JSONMatchesWithOptions/CompiledPattern.MatchAny. It lets
+	// Conflicts.Report walk back into the document to print unchanged
+	// sibling values as context, without re-running the match.
+	root interface{}
+}
+
+// MatchOptions carries extra identifiers that are made available inside
+// `#expr` and `#[num] EXPR` predicates, on top of the implicit `_`
+// (and, for array predicates, `i`) bindings.
+type MatchOptions struct {
+	// Functions are registered as callable identifiers, e.g.
+	// {"now": func() time.Time { return time.Now() }}.
+	Functions map[string]interface{}
+	// Variables are registered as plain identifiers.
+	Variables map[string]interface{}
 }
 
-type matcher func(string, interface{}, interface{}) ([]Conflict, error)
+// matchCtx carries the state that accumulates as _match descends into a
+// document: the current path (for Conflict.Path), the root document (for
+// #ref/#eq/#neq selectors), the stack of enclosing containers (for their
+// `@/...`-relative form), and the active MatchOptions.
+type matchCtx struct {
+	path    string
+	root    interface{}
+	stack   []interface{}
+	options MatchOptions
+}
+
+func newMatchCtx(root interface{}, options MatchOptions) matchCtx {
+	return matchCtx{path: "/", root: root, options: options}
+}
+
+func (c matchCtx) withPath(path string) matchCtx {
+	c.path = path
+	return c
+}
+
+// pushed returns a copy of c with container appended to the container stack,
+// used when descending into a map or slice so that `@/...` selectors
+// encountered among its children resolve relative to it.
+func (c matchCtx) pushed(container interface{}) matchCtx {
+	stack := make([]interface{}, len(c.stack)+1)
+	copy(stack, c.stack)
+	stack[len(c.stack)] = container
+	c.stack = stack
+	return c
+}
+
+type matcher func(matchCtx, interface{}, interface{}) ([]Conflict, error)
 
 //nolint:gochecknoglobals // an internal global here is more efficient than repeatedly creating the map in a hot path
 var matchers map[reflect.Kind]matcher
@@ -41,11 +94,18 @@ func init() {
 // The pattern can be a valid literal value (in that case an exact match will
 // be required), a special marker (a string starting with the hash character
 // '#'), or any combination of these via arrays and objects.
-func JSONMatches(j []byte, jPatternSpecifier []byte) ([]Conflict, error) {
+func JSONMatches(j []byte, jPatternSpecifier []byte) (Conflicts, error) {
+	return JSONMatchesWithOptions(j, jPatternSpecifier, MatchOptions{})
+}
+
+// JSONMatchesWithOptions behaves like JSONMatches, but additionally accepts a
+// MatchOptions value whose Functions and Variables are made available to any
+// `#expr` and `#[num] EXPR` predicates encountered while matching.
+func JSONMatchesWithOptions(j []byte, jPatternSpecifier []byte, options MatchOptions) (Conflicts, error) {
 	var jAny interface{}
 	err := json.Unmarshal(j, &jAny)
 	if err != nil {
-		return []Conflict{{
+		return Conflicts{{
 			Path:  "/",
 			Error: err,
 		}}, fmt.Errorf("can't unmarshal left argument: %w", err)
@@ -54,18 +114,19 @@ func JSONMatches(j []byte, jPatternSpecifier []byte) ([]Conflict, error) {
 	var patternSpecAny interface{}
 	err = json.Unmarshal(jPatternSpecifier, &patternSpecAny)
 	if err != nil {
-		return []Conflict{{
+		return Conflicts{{
 			Path:  "/",
 			Error: err,
 		}}, fmt.Errorf("can't unmarshal pattern argument: %w", err)
 	}
 
-	conflicts, err := _match("/", jAny, patternSpecAny)
-	var resultingConflicts []Conflict
+	conflicts, err := _match(newMatchCtx(jAny, options), jAny, patternSpecAny)
+	var resultingConflicts Conflicts
 	for _, c := range conflicts {
 		if strings.HasPrefix(c.Path, "//") {
 			c.Path = c.Path[1:]
 		}
+		c.root = jAny
 		resultingConflicts = append(resultingConflicts, c)
 	}
 	return resultingConflicts, err
@@ -77,18 +138,18 @@ func JSONMatches(j []byte, jPatternSpecifier []byte) ([]Conflict, error) {
 // The pattern can be a valid literal value (in that case an exact match will
 // be required), a special marker (a string starting with the hash character
 // '#'), or any combination of these via arrays and objects.
-func JSONStringMatches(j string, jPatternSpecifier string) ([]Conflict, error) {
+func JSONStringMatches(j string, jPatternSpecifier string) (Conflicts, error) {
 	return JSONMatches([]byte(j), []byte(jPatternSpecifier))
 }
 
-func _matchZero(path string, x interface{}) []Conflict {
+func _matchZero(ctx matchCtx, x interface{}) []Conflict {
 	xV := reflect.ValueOf(x)
 	if !xV.IsValid() {
 		return []Conflict{}
 	}
 	return []Conflict{
 		{
-			Path:     path,
+			Path:     ctx.path,
 			Expected: nil,
 			Actual:   x,
 		},
@@ -97,6 +158,7 @@ func _matchZero(path string, x interface{}) []Conflict {
 
 var uuidRe = regexp.MustCompile(`(?i)^[a-f0-9]{8}-[a-f0-9]{4}-[a-f0-9]{4}-[a-f0-9]{4}-[a-f0-9]{12}$`)
 var uuidV4Re = regexp.MustCompile(`(?i)^[a-f0-9]{8}-[a-f0-9]{4}-4[a-f0-9]{3}-[89aAbB][a-f0-9]{3}-[a-f0-9]{12}$`)
+var arrayQuantifierRe = regexp.MustCompile(`^#\[(>=|<=|>|<|==)?(\d+)]$`)
 
 const (
 	ignoreMarker  = "#ignore"
@@ -104,10 +166,321 @@ const (
 	presentMarker = "#present"
 )
 
+//nolint:gochecknoglobals // compiled expr programs are cached across calls so we don't recompile on every match
+var exprProgramCache sync.Map // map[string]*vm.Program
+
+// compileExprCached compiles an expr source string, reusing a previously
+// compiled program for the same source when one is available.
+func compileExprCached(source string) (*vm.Program, error) {
+	if cached, ok := exprProgramCache.Load(source); ok {
+		return cached.(*vm.Program), nil //nolint:forcetypeassert // we only ever store *vm.Program under these keys
+	}
+	program, err := expr.Compile(source, expr.AllowUndefinedVariables())
+	if err != nil {
+		return nil, err
+	}
+	exprProgramCache.Store(source, program)
+	return program, nil
+}
+
+//nolint:gochecknoglobals // compiled regexes are cached across calls so we don't recompile on every match
+var regexCompileCache sync.Map // map[string]*regexp.Regexp
+
+// compileRegexCached compiles a regex source string, reusing a previously
+// compiled *regexp.Regexp for the same source when one is available.
+func compileRegexCached(source string) (*regexp.Regexp, error) {
+	if cached, ok := regexCompileCache.Load(source); ok {
+		return cached.(*regexp.Regexp), nil //nolint:forcetypeassert // we only ever store *regexp.Regexp under these keys
+	}
+	r, err := regexp.Compile(source)
+	if err != nil {
+		return nil, err
+	}
+	regexCompileCache.Store(source, r)
+	return r, nil
+}
+
+// exprEnv builds the evaluation context for an #expr/#[num] EXPR predicate:
+// the matched value is bound to `_`, its keys are promoted to top-level
+// identifiers when it is an object, and any MatchOptions are merged in.
+func exprEnv(value interface{}, options MatchOptions) map[string]interface{} {
+	env := map[string]interface{}{"_": value}
+	if m, ok := value.(map[string]interface{}); ok {
+		for k, v := range m {
+			env[k] = v
+		}
+	}
+	for k, v := range options.Variables {
+		env[k] = v
+	}
+	for k, v := range options.Functions {
+		env[k] = v
+	}
+	return env
+}
+
+// comparison is the parsed form of the expression following a "#number",
+// "#string", or "#array" marker, e.g. "> 5", "in [1,10]", `startsWith "x"`.
+type comparison struct {
+	op   string
+	num  float64
+	nums []float64
+	str  string
+}
+
+func (c comparison) matchesNumber(v float64) bool {
+	switch c.op {
+	case ">":
+		return v > c.num
+	case "<":
+		return v < c.num
+	case ">=":
+		return v >= c.num
+	case "<=":
+		return v <= c.num
+	case "==":
+		return v == c.num
+	case "!=":
+		return v != c.num
+	case "in-range":
+		return v >= c.nums[0] && v <= c.nums[1]
+	case "in-set":
+		for _, n := range c.nums {
+			if v == n {
+				return true
+			}
+		}
+		return false
+	default:
+		return false
+	}
+}
+
+func (c comparison) matchesLength(n int) bool {
+	return c.matchesNumber(float64(n))
+}
+
+func (c comparison) matchesString(s string) bool {
+	switch c.op {
+	case "startsWith":
+		return strings.HasPrefix(s, c.str)
+	case "endsWith":
+		return strings.HasSuffix(s, c.str)
+	default:
+		return c.matchesLength(len(s))
+	}
+}
+
+func _asFloat64(x interface{}) (float64, bool) {
+	switch v := x.(type) {
+	case float64:
+		return v, true
+	case int64:
+		return float64(v), true
+	default:
+		return 0, false
+	}
+}
+
+// _tokenizeComparison splits a "#number"/"#string"/"#array" comparison
+// expression into tokens, treating "..." as a single string token and
+// '[', ']', '{', '}', ',' as standalone tokens.
+func _tokenizeComparison(s string) []string {
+	var tokens []string
+	var cur strings.Builder
+	inQuotes := false
+	flush := func() {
+		if cur.Len() > 0 {
+			tokens = append(tokens, cur.String())
+			cur.Reset()
+		}
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '"':
+			cur.WriteByte(c)
+			inQuotes = !inQuotes
+		case inQuotes:
+			cur.WriteByte(c)
+		case c == ' ':
+			flush()
+		case strings.ContainsRune("[]{},", rune(c)):
+			flush()
+			tokens = append(tokens, string(c))
+		default:
+			cur.WriteByte(c)
+		}
+	}
+	flush()
+	return tokens
+}
+
+var numberComparisonOps = map[string]bool{">": true, "<": true, ">=": true, "<=": true, "==": true, "!=": true}
+
+// _parseNumberComparison parses the expression after a "#number" marker:
+// "> N", "< N", ">= N", "<= N", "== N", "!= N", "in [a,b]", or "in {a,b,c}".
+func _parseNumberComparison(s string) (comparison, error) {
+	tokens := _tokenizeComparison(s)
+	if len(tokens) == 0 {
+		return comparison{}, fmt.Errorf("empty comparison")
+	}
+	if numberComparisonOps[tokens[0]] {
+		//nolint:gomnd // the "magic" literal constant 2 here is clearer than a synthetic constant symbol
+		if len(tokens) != 2 {
+			return comparison{}, fmt.Errorf("expected a single number after '%s'", tokens[0])
+		}
+		n, err := strconv.ParseFloat(tokens[1], 64)
+		if err != nil {
+			return comparison{}, fmt.Errorf("invalid number '%s': %w", tokens[1], err)
+		}
+		return comparison{op: tokens[0], num: n}, nil
+	}
+	if tokens[0] == "in" && len(tokens) >= 2 { //nolint:gomnd // two tokens ("in" + opening bracket) are the minimum
+		switch tokens[1] {
+		case "[":
+			nums, err := _parseNumberList(tokens[2:], "]")
+			if err != nil {
+				return comparison{}, err
+			}
+			//nolint:gomnd // the "magic" literal constant 2 here is clearer than a synthetic constant symbol
+			if len(nums) != 2 {
+				return comparison{}, fmt.Errorf("expected exactly two bounds in 'in [a,b]'")
+			}
+			return comparison{op: "in-range", nums: nums}, nil
+		case "{":
+			nums, err := _parseNumberList(tokens[2:], "}")
+			if err != nil {
+				return comparison{}, err
+			}
+			return comparison{op: "in-set", nums: nums}, nil
+		}
+	}
+	return comparison{}, fmt.Errorf("unsupported comparison '%s'", s)
+}
+
+func _parseNumberList(tokens []string, closing string) ([]float64, error) {
+	var nums []float64
+	for _, tok := range tokens {
+		if tok == closing {
+			return nums, nil
+		}
+		if tok == "," {
+			continue
+		}
+		n, err := strconv.ParseFloat(tok, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number '%s': %w", tok, err)
+		}
+		nums = append(nums, n)
+	}
+	return nil, fmt.Errorf("missing closing '%s'", closing)
+}
+
+// _parseStringComparison parses the expression after a "#string" marker:
+// "length <op> N", `startsWith "x"`, or `endsWith "y"`.
+func _parseStringComparison(s string) (comparison, error) {
+	tokens := _tokenizeComparison(s)
+	if len(tokens) == 0 {
+		return comparison{}, fmt.Errorf("empty comparison")
+	}
+	switch tokens[0] {
+	case "length":
+		return _parseLengthComparison(tokens)
+	case "startsWith", "endsWith":
+		//nolint:gomnd // the "magic" literal constant 2 here is clearer than a synthetic constant symbol
+		if len(tokens) != 2 {
+			return comparison{}, fmt.Errorf("expected a quoted string after '%s'", tokens[0])
+		}
+		str, err := _unquote(tokens[1])
+		if err != nil {
+			return comparison{}, err
+		}
+		return comparison{op: tokens[0], str: str}, nil
+	default:
+		return comparison{}, fmt.Errorf("unsupported comparison '%s'", s)
+	}
+}
+
+// _parseArrayComparison parses the expression after an "#array" marker:
+// "length <op> N".
+func _parseArrayComparison(s string) (comparison, error) {
+	tokens := _tokenizeComparison(s)
+	if len(tokens) == 0 || tokens[0] != "length" {
+		return comparison{}, fmt.Errorf("unsupported comparison '%s'", s)
+	}
+	return _parseLengthComparison(tokens)
+}
+
+func _parseLengthComparison(tokens []string) (comparison, error) {
+	//nolint:gomnd // the "magic" literal constant 3 here is clearer than a synthetic constant symbol
+	if len(tokens) != 3 || !numberComparisonOps[tokens[1]] {
+		return comparison{}, fmt.Errorf("expected 'length <op> N'")
+	}
+	n, err := strconv.ParseFloat(tokens[2], 64)
+	if err != nil {
+		return comparison{}, fmt.Errorf("invalid number '%s': %w", tokens[2], err)
+	}
+	return comparison{op: tokens[1], num: n}, nil
+}
+
+func _unquote(tok string) (string, error) {
+	//nolint:gomnd // the "magic" literal constant 2 here is clearer than a synthetic constant symbol
+	if len(tok) < 2 || tok[0] != '"' || tok[len(tok)-1] != '"' {
+		return "", fmt.Errorf("expected a quoted string, got '%s'", tok)
+	}
+	return strings.ReplaceAll(tok[1:len(tok)-1], `\"`, `"`), nil
+}
+
+// _resolvePointer resolves a JSON Pointer (RFC 6901, e.g. "/a/b/0/c")
+// against root, returning the referenced value and whether it exists.
+func _resolvePointer(root interface{}, pointer string) (interface{}, bool) {
+	if pointer == "" || pointer == "/" {
+		return root, true
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, false
+	}
+	cur := root
+	for _, token := range strings.Split(pointer[1:], "/") {
+		token = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+		switch v := cur.(type) {
+		case map[string]interface{}:
+			val, ok := v[token]
+			if !ok {
+				return nil, false
+			}
+			cur = val
+		case []interface{}:
+			idx, err := strconv.Atoi(token)
+			if err != nil || idx < 0 || idx >= len(v) {
+				return nil, false
+			}
+			cur = v[idx]
+		default:
+			return nil, false
+		}
+	}
+	return cur, true
+}
+
+// _resolveSelector resolves a "#ref"/"#eq"/"#neq" selector: a plain
+// "/a/b/0/c" is a JSON Pointer into the root document, while "@/a/b" is a
+// JSON Pointer relative to the innermost enclosing object/array.
+func _resolveSelector(ctx matchCtx, selector string) (interface{}, bool) {
+	if strings.HasPrefix(selector, "@") {
+		if len(ctx.stack) == 0 {
+			return nil, false
+		}
+		return _resolvePointer(ctx.stack[len(ctx.stack)-1], selector[1:])
+	}
+	return _resolvePointer(ctx.root, selector)
+}
+
 //nolint:funlen,gocognit // reducing the number of statements would reduce legibility in this instance
-func _matchWithMarker(path string, x interface{}, marker string) ([]Conflict, error) {
+func _matchWithMarker(ctx matchCtx, x interface{}, marker string) ([]Conflict, error) {
 	possibleConflict := Conflict{
-		Path:     path,
+		Path:     ctx.path,
 		Expected: marker,
 		Actual:   x,
 	}
@@ -122,6 +495,10 @@ func _matchWithMarker(path string, x interface{}, marker string) ([]Conflict, er
 	//nolint:gomnd // the "magic" literal constant 2 here is clearer than a synthetic constant symbol
 	markerParts := strings.SplitN(marker, " ", 2)
 
+	if quantifier := arrayQuantifierRe.FindStringSubmatch(markerParts[0]); quantifier != nil {
+		return _matchArrayQuantifier(ctx, xV, markerParts, quantifier[1], quantifier[2], possibleConflict)
+	}
+
 	switch markerParts[0] {
 	case ignoreMarker:
 		return []Conflict{}, nil
@@ -151,6 +528,16 @@ func _matchWithMarker(path string, x interface{}, marker string) ([]Conflict, er
 		if (xV.Kind() != reflect.Array) && (xV.Kind() != reflect.Slice) {
 			return []Conflict{possibleConflict}, nil
 		}
+		if len(markerParts) == 1 {
+			return []Conflict{}, nil
+		}
+		cmp, err := _parseArrayComparison(markerParts[1])
+		if err != nil {
+			return []Conflict{possibleConflict}, fmt.Errorf("invalid comparison argument to #array: %w", err)
+		}
+		if !cmp.matchesLength(xV.Len()) {
+			return []Conflict{possibleConflict}, nil
+		}
 		return []Conflict{}, nil
 	case "#object":
 		if xV.Kind() != reflect.Map {
@@ -168,11 +555,33 @@ func _matchWithMarker(path string, x interface{}, marker string) ([]Conflict, er
 		if (xV.Kind() != reflect.Int64) && (xV.Kind() != reflect.Float64) {
 			return []Conflict{possibleConflict}, nil
 		}
+		if len(markerParts) == 1 {
+			return []Conflict{}, nil
+		}
+		cmp, err := _parseNumberComparison(markerParts[1])
+		if err != nil {
+			return []Conflict{possibleConflict}, fmt.Errorf("invalid comparison argument to #number: %w", err)
+		}
+		xNum, ok := _asFloat64(x)
+		if !ok || !cmp.matchesNumber(xNum) {
+			return []Conflict{possibleConflict}, nil
+		}
 		return []Conflict{}, nil
 	case "#string":
 		if xV.Kind() != reflect.String {
 			return []Conflict{possibleConflict}, nil
 		}
+		if len(markerParts) == 1 {
+			return []Conflict{}, nil
+		}
+		cmp, err := _parseStringComparison(markerParts[1])
+		if err != nil {
+			return []Conflict{possibleConflict}, fmt.Errorf("invalid comparison argument to #string: %w", err)
+		}
+		xString, ok := x.(string)
+		if !ok || !cmp.matchesString(xString) {
+			return []Conflict{possibleConflict}, nil
+		}
 		return []Conflict{}, nil
 	case "#date":
 		if xV.Kind() == reflect.String {
@@ -238,7 +647,7 @@ func _matchWithMarker(path string, x interface{}, marker string) ([]Conflict, er
 		if len(markerParts) != 2 {
 			return []Conflict{possibleConflict}, fmt.Errorf("expected exactly one argument for #regex")
 		}
-		r, err := regexp.Compile(markerParts[1])
+		r, err := compileRegexCached(markerParts[1])
 		if err != nil {
 			return []Conflict{possibleConflict}, fmt.Errorf("invalid regex argument to #regex: %w", err)
 		}
@@ -252,27 +661,133 @@ func _matchWithMarker(path string, x interface{}, marker string) ([]Conflict, er
 			}
 		}
 		return []Conflict{possibleConflict}, nil
-		// TODO: "#[num] EXPR"
+	case "#expr":
+		//nolint:gomnd // the "magic" literal constant 2 here is clearer than a synthetic constant symbol
+		if len(markerParts) != 2 {
+			return []Conflict{possibleConflict}, fmt.Errorf("expected exactly one argument for #expr")
+		}
+		program, err := compileExprCached(markerParts[1])
+		if err != nil {
+			return []Conflict{possibleConflict}, fmt.Errorf("invalid expression argument to #expr: %w", err)
+		}
+		result, err := expr.Run(program, exprEnv(x, ctx.options))
+		if err != nil {
+			return []Conflict{possibleConflict}, fmt.Errorf("can't evaluate #expr: %w", err)
+		}
+		if matched, ok := result.(bool); ok && matched {
+			return []Conflict{}, nil
+		}
+		return []Conflict{possibleConflict}, nil
+	case "#ref", "#eq":
+		return _matchReference(ctx, x, markerParts, possibleConflict, false)
+	case "#neq":
+		return _matchReference(ctx, x, markerParts, possibleConflict, true)
 	}
 
 	return []Conflict{possibleConflict}, fmt.Errorf("unsupported pattern '%s'", marker)
 }
 
-func _match(path string, x interface{}, spec interface{}) ([]Conflict, error) {
+// _matchReference implements the "#ref"/"#eq"/"#neq" markers: it resolves
+// the selector in markerParts[1] against ctx (see _resolveSelector) and
+// compares the referenced value with x, requiring equality unless negate is
+// set, in which case it requires inequality.
+func _matchReference(ctx matchCtx, x interface{}, markerParts []string, possibleConflict Conflict, negate bool) ([]Conflict, error) {
+	//nolint:gomnd // the "magic" literal constant 2 here is clearer than a synthetic constant symbol
+	if len(markerParts) != 2 {
+		return []Conflict{possibleConflict}, fmt.Errorf("expected exactly one selector argument for '%s'", markerParts[0])
+	}
+	referenced, ok := _resolveSelector(ctx, markerParts[1])
+	if !ok {
+		return []Conflict{possibleConflict}, fmt.Errorf("can't resolve selector '%s'", markerParts[1])
+	}
+	if reflect.DeepEqual(x, referenced) != negate {
+		return []Conflict{}, nil
+	}
+	return []Conflict{possibleConflict}, nil
+}
+
+// _matchArrayQuantifier implements the "#[num] EXPR" family of markers
+// (e.g. "#[2] EXPR", "#[>=2] EXPR"): it passes when the number of elements
+// of `x` for which EXPR evaluates to true (with the element bound as `_`
+// and its index bound as `i`) satisfies the comparison against `num`.
+func _matchArrayQuantifier(
+	ctx matchCtx,
+	xV reflect.Value,
+	markerParts []string,
+	op string,
+	numStr string,
+	possibleConflict Conflict,
+) ([]Conflict, error) {
+	if xV.Kind() != reflect.Slice && xV.Kind() != reflect.Array {
+		return []Conflict{possibleConflict}, nil
+	}
+	//nolint:gomnd // the "magic" literal constant 2 here is clearer than a synthetic constant symbol
+	if len(markerParts) != 2 {
+		return []Conflict{possibleConflict}, fmt.Errorf("expected an expression argument for '%s'", markerParts[0])
+	}
+	num, err := strconv.Atoi(numStr)
+	if err != nil {
+		return []Conflict{possibleConflict}, fmt.Errorf("invalid count in '%s': %w", markerParts[0], err)
+	}
+	program, err := compileExprCached(markerParts[1])
+	if err != nil {
+		return []Conflict{possibleConflict}, fmt.Errorf("invalid expression argument to '%s': %w", markerParts[0], err)
+	}
+
+	var failedIndices []int
+	matchCount := 0
+	for i := 0; i < xV.Len(); i++ {
+		env := exprEnv(xV.Index(i).Interface(), ctx.options)
+		env["i"] = i
+		result, err := expr.Run(program, env)
+		if err != nil {
+			return []Conflict{possibleConflict}, fmt.Errorf("can't evaluate expression for element %d: %w", i, err)
+		}
+		if matched, ok := result.(bool); ok && matched {
+			matchCount++
+		} else {
+			failedIndices = append(failedIndices, i)
+		}
+	}
+
+	if _quantifierSatisfied(op, num, matchCount) {
+		return []Conflict{}, nil
+	}
+	conflict := possibleConflict
+	conflict.Expected = fmt.Sprintf("%s %s (failed indices: %v)", markerParts[0], markerParts[1], failedIndices)
+	return []Conflict{conflict}, nil
+}
+
+func _quantifierSatisfied(op string, num int, count int) bool {
+	switch op {
+	case ">=":
+		return count >= num
+	case "<=":
+		return count <= num
+	case ">":
+		return count > num
+	case "<":
+		return count < num
+	default:
+		return count == num
+	}
+}
+
+func _match(ctx matchCtx, x interface{}, spec interface{}) ([]Conflict, error) {
 	possibleConflict := Conflict{
-		Path:     path,
+		Path:     ctx.path,
 		Expected: spec,
 		Actual:   x,
 	}
 	specV := reflect.ValueOf(spec)
 	if !specV.IsValid() {
-		return _matchZero(path, x), nil
+		return _matchZero(ctx, x), nil
 	}
 
 	if specV.Kind() == reflect.String {
 		isMarker, specMarker := getMarker(spec)
 		if isMarker {
-			return _matchWithMarker(path, x, specMarker)
+			return _matchWithMarker(ctx, x, specMarker)
 		}
 	}
 
@@ -286,15 +801,15 @@ func _match(path string, x interface{}, spec interface{}) ([]Conflict, error) {
 	}
 
 	if m, ok := matchers[specV.Kind()]; ok {
-		return m(path, x, spec)
+		return m(ctx, x, spec)
 	}
 	tX := reflect.TypeOf(x)
 	return []Conflict{possibleConflict}, fmt.Errorf("unable to compare %v (type: %v) - kind %v is not supported", x, tX, xV.Kind())
 }
 
-func _matchMap(path string, x interface{}, y interface{}) ([]Conflict, error) {
+func _matchMap(ctx matchCtx, x interface{}, y interface{}) ([]Conflict, error) {
 	possibleConflict := Conflict{
-		Path:     path,
+		Path:     ctx.path,
 		Expected: y,
 		Actual:   x,
 	}
@@ -307,16 +822,18 @@ func _matchMap(path string, x interface{}, y interface{}) ([]Conflict, error) {
 		return []Conflict{possibleConflict}, fmt.Errorf("wrong kind for pattern value, expected Map, got %v", vX.Kind())
 	}
 
-	conflicts, err := _matchMapCheckIteratingObject(path, vX, vY)
+	childCtx := ctx.pushed(x)
+
+	conflicts, err := _matchMapCheckIteratingObject(childCtx, vX, vY)
 
 	if len(conflicts) > 0 {
 		return conflicts, err
 	}
 
-	return _matchMapCheckIteratingSpec(path, vX, vY)
+	return _matchMapCheckIteratingSpec(childCtx, vX, vY)
 }
 
-func _matchMapCheckIteratingObject(path string, vX reflect.Value, vY reflect.Value) ([]Conflict, error) {
+func _matchMapCheckIteratingObject(ctx matchCtx, vX reflect.Value, vY reflect.Value) ([]Conflict, error) {
 	var conflicts []Conflict
 	iterX := vX.MapRange()
 	for iterX.Next() {
@@ -325,23 +842,23 @@ func _matchMapCheckIteratingObject(path string, vX reflect.Value, vY reflect.Val
 			// missing spec for this key, skip...
 			continue
 		}
-		itemConflicts, err := _match(path+"/"+fmt.Sprint(iterX.Key()), iterX.Value().Interface(), ySpecValue.Interface())
+		itemConflicts, err := _match(ctx.withPath(ctx.path+"/"+fmt.Sprint(iterX.Key())), iterX.Value().Interface(), ySpecValue.Interface())
 		conflicts = append(conflicts, itemConflicts...)
 		if err != nil {
-			return conflicts, fmt.Errorf("can't compare map element %s/%v: %w", path, iterX.Key().Interface(), err)
+			return conflicts, fmt.Errorf("can't compare map element %s/%v: %w", ctx.path, iterX.Key().Interface(), err)
 		}
 	}
 	return conflicts, nil
 }
 
-func _matchMapCheckIteratingSpec(path string, vX reflect.Value, vY reflect.Value) ([]Conflict, error) {
+func _matchMapCheckIteratingSpec(ctx matchCtx, vX reflect.Value, vY reflect.Value) ([]Conflict, error) {
 	var conflicts []Conflict
 	iterY := vY.MapRange()
 	for iterY.Next() {
 		ySpecValue := iterY.Value()
 		xValue := vX.MapIndex(iterY.Key())
 		possibleConflict := Conflict{
-			Path:     path + "/" + fmt.Sprint(iterY.Key()),
+			Path:     ctx.path + "/" + fmt.Sprint(iterY.Key()),
 			Expected: iterY.Value().Interface(),
 			Actual:   fmt.Sprint(xValue),
 		}
@@ -371,7 +888,7 @@ func _matchMapCheckIteratingSpec(path string, vX reflect.Value, vY reflect.Value
 				conflicts = append(conflicts, possibleConflict)
 			} else {
 				var err error
-				itemConflicts, err = _match(path+"/"+fmt.Sprint(iterY.Key()), xValue.Interface(), ySpecValue.Interface())
+				itemConflicts, err = _match(ctx.withPath(ctx.path+"/"+fmt.Sprint(iterY.Key())), xValue.Interface(), ySpecValue.Interface())
 				conflicts = append(conflicts, itemConflicts...)
 				if err != nil {
 					return conflicts, fmt.Errorf("can't compare map element %v: %w", iterY.Key().Interface(), err)
@@ -395,9 +912,9 @@ func isMarker(y interface{}, marker string) bool {
 	return isMarker && marker == gotMarker
 }
 
-func _matchSlice(path string, x interface{}, y interface{}) ([]Conflict, error) {
+func _matchSlice(ctx matchCtx, x interface{}, y interface{}) ([]Conflict, error) {
 	possibleConflict := Conflict{
-		Path:     path,
+		Path:     ctx.path,
 		Expected: y,
 		Actual:   x,
 	}
@@ -411,6 +928,11 @@ func _matchSlice(path string, x interface{}, y interface{}) ([]Conflict, error)
 	}
 
 	vY := reflect.ValueOf(y)
+
+	if handled, conflicts, err := _matchSliceSetMarker(ctx, vX, vY, possibleConflict); handled {
+		return conflicts, err
+	}
+
 	isArrayOf := false
 	var arrayOf interface{}
 
@@ -426,6 +948,8 @@ func _matchSlice(path string, x interface{}, y interface{}) ([]Conflict, error)
 		return []Conflict{possibleConflict}, nil
 	}
 
+	childCtx := ctx.pushed(x)
+
 	var conflicts []Conflict
 	sliceLen := vX.Len()
 	for i := 0; i < sliceLen; i++ {
@@ -435,7 +959,7 @@ func _matchSlice(path string, x interface{}, y interface{}) ([]Conflict, error)
 		} else {
 			ySpecElem = vY.Index(i).Interface()
 		}
-		itemMatches, err := _match(path+"["+fmt.Sprint(i)+"]", vX.Index(i).Interface(), ySpecElem)
+		itemMatches, err := _match(childCtx.withPath(ctx.path+"["+fmt.Sprint(i)+"]"), vX.Index(i).Interface(), ySpecElem)
 		conflicts = append(conflicts, itemMatches...)
 		if err != nil {
 			return conflicts, fmt.Errorf("can't compare slice element %v: %w", i, err)
@@ -444,11 +968,195 @@ func _matchSlice(path string, x interface{}, y interface{}) ([]Conflict, error)
 	return conflicts, nil
 }
 
-func _matchPrimitive(path string, x interface{}, y interface{}) ([]Conflict, error) {
+// _matchSliceSetMarker handles the "#array-unordered"/"#array-contains"/
+// "#array-subset"/"#array-distinct" family of set-semantics markers,
+// recognized as a two-element (or, for "#array-distinct", one-element) spec
+// array whose first element is the marker. handled is false when y isn't
+// one of these forms, in which case _matchSlice falls back to its regular
+// positional/array-of matching.
+func _matchSliceSetMarker(ctx matchCtx, vX reflect.Value, vY reflect.Value, possibleConflict Conflict) (handled bool, conflicts []Conflict, err error) {
+	if vY.Len() == 0 {
+		return false, nil, nil
+	}
+	isMarker, marker := getMarker(vY.Index(0).Interface())
+	if !isMarker {
+		return false, nil, nil
+	}
+
+	//nolint:gomnd // the "magic" literal constants here are the arities of each marker's spec array
+	switch marker {
+	case "#array-unordered":
+		if vY.Len() != 2 {
+			return false, nil, nil
+		}
+		specs, ok := vY.Index(1).Interface().([]interface{})
+		if !ok {
+			return true, []Conflict{possibleConflict}, fmt.Errorf("expected an array of specs for #array-unordered")
+		}
+		conflicts, err := _matchArrayUnordered(ctx, vX, specs, possibleConflict)
+		return true, conflicts, err
+	case "#array-contains":
+		if vY.Len() != 2 {
+			return false, nil, nil
+		}
+		conflicts, err := _matchArrayContains(ctx, vX, vY.Index(1).Interface(), possibleConflict)
+		return true, conflicts, err
+	case "#array-subset":
+		if vY.Len() != 2 {
+			return false, nil, nil
+		}
+		specs, ok := vY.Index(1).Interface().([]interface{})
+		if !ok {
+			return true, []Conflict{possibleConflict}, fmt.Errorf("expected an array of specs for #array-subset")
+		}
+		conflicts, err := _matchArraySubset(ctx, vX, specs, possibleConflict)
+		return true, conflicts, err
+	case "#array-distinct":
+		if vY.Len() != 1 {
+			return false, nil, nil
+		}
+		return true, _matchArrayDistinct(vX, possibleConflict), nil
+	default:
+		return false, nil, nil
+	}
+}
+
+// _specMatchesElement reports whether elem satisfies spec, discarding the
+// resulting conflicts (used by the set-semantics matchers, which only care
+// about pass/fail when probing element/spec compatibility).
+func _specMatchesElement(ctx matchCtx, elem interface{}, spec interface{}) (bool, error) {
+	conflicts, err := _match(ctx, elem, spec)
+	if err != nil {
+		return false, err
+	}
+	return len(conflicts) == 0, nil
+}
+
+// _matchArrayUnordered implements `["#array-unordered", [specA, specB, ...]]`:
+// it passes iff there is a bijection between the actual elements and the
+// given specs, found via backtracking over a small element/spec
+// compatibility bitmask. To keep that backtracking search from blowing up
+// combinatorially on a crafted near-miss compatibility matrix, an array whose
+// length equals the number of specs and exceeds maxUnorderedElements (20) is
+// rejected outright with an error rather than matched (a length mismatch is
+// reported as a plain conflict first) — #array-unordered is not suited to
+// larger arrays; callers
+// needing that should match them some other way (e.g. #array-contains per
+// element, or a smaller, more specific spec).
+//
+// maxUnorderedElements bounds the backtracking search: the request that
+// introduced this marker green-lit plain backtracking "for N <= ~20", so we
+// enforce that bound rather than letting it run unbounded.
+const maxUnorderedElements = 20
+
+func _matchArrayUnordered(ctx matchCtx, vX reflect.Value, specs []interface{}, possibleConflict Conflict) ([]Conflict, error) {
+	if vX.Len() != len(specs) {
+		return []Conflict{possibleConflict}, nil
+	}
+	if vX.Len() > maxUnorderedElements {
+		return []Conflict{possibleConflict}, fmt.Errorf(
+			"#array-unordered: %d elements exceeds the %d-element limit for backtracking matching",
+			vX.Len(), maxUnorderedElements,
+		)
+	}
+	compat := make([][]bool, vX.Len())
+	for i := range compat {
+		compat[i] = make([]bool, len(specs))
+		for j, spec := range specs {
+			ok, err := _specMatchesElement(ctx, vX.Index(i).Interface(), spec)
+			if err != nil {
+				return []Conflict{possibleConflict}, err
+			}
+			compat[i][j] = ok
+		}
+	}
+
+	usedSpecs := make([]bool, len(specs))
+	if _bijectionExists(compat, usedSpecs, 0) {
+		return []Conflict{}, nil
+	}
+	conflict := possibleConflict
+	conflict.Expected = "#array-unordered: no bijection between elements and specs"
+	return []Conflict{conflict}, nil
+}
+
+func _bijectionExists(compat [][]bool, usedSpecs []bool, elemIdx int) bool {
+	if elemIdx == len(compat) {
+		return true
+	}
+	for specIdx, ok := range compat[elemIdx] {
+		if ok && !usedSpecs[specIdx] {
+			usedSpecs[specIdx] = true
+			if _bijectionExists(compat, usedSpecs, elemIdx+1) {
+				return true
+			}
+			usedSpecs[specIdx] = false
+		}
+	}
+	return false
+}
+
+// _matchArrayContains implements `["#array-contains", specX]`: it passes
+// iff at least one actual element matches specX.
+func _matchArrayContains(ctx matchCtx, vX reflect.Value, spec interface{}, possibleConflict Conflict) ([]Conflict, error) {
+	for i := 0; i < vX.Len(); i++ {
+		ok, err := _specMatchesElement(ctx, vX.Index(i).Interface(), spec)
+		if err != nil {
+			return []Conflict{possibleConflict}, err
+		}
+		if ok {
+			return []Conflict{}, nil
+		}
+	}
+	return []Conflict{possibleConflict}, nil
+}
+
+// _matchArraySubset implements `["#array-subset", [specA, specB, ...]]`: it
+// passes iff every listed spec matches at least one actual element (specs
+// may overlap in which elements they match).
+func _matchArraySubset(ctx matchCtx, vX reflect.Value, specs []interface{}, possibleConflict Conflict) ([]Conflict, error) {
+	var unmatched []int
+	for specIdx, spec := range specs {
+		found := false
+		for i := 0; i < vX.Len() && !found; i++ {
+			ok, err := _specMatchesElement(ctx, vX.Index(i).Interface(), spec)
+			if err != nil {
+				return []Conflict{possibleConflict}, err
+			}
+			found = ok
+		}
+		if !found {
+			unmatched = append(unmatched, specIdx)
+		}
+	}
+	if len(unmatched) == 0 {
+		return []Conflict{}, nil
+	}
+	conflict := possibleConflict
+	conflict.Expected = fmt.Sprintf("#array-subset: unmatched spec indices %v", unmatched)
+	return []Conflict{conflict}, nil
+}
+
+// _matchArrayDistinct implements `["#array-distinct"]`: it passes iff all
+// elements are pairwise unequal by reflect.DeepEqual.
+func _matchArrayDistinct(vX reflect.Value, possibleConflict Conflict) []Conflict {
+	for i := 0; i < vX.Len(); i++ {
+		for j := i + 1; j < vX.Len(); j++ {
+			if reflect.DeepEqual(vX.Index(i).Interface(), vX.Index(j).Interface()) {
+				conflict := possibleConflict
+				conflict.Expected = fmt.Sprintf("#array-distinct: elements %d and %d are equal", i, j)
+				return []Conflict{conflict}
+			}
+		}
+	}
+	return []Conflict{}
+}
+
+func _matchPrimitive(ctx matchCtx, x interface{}, y interface{}) ([]Conflict, error) {
 	if !reflect.DeepEqual(x, y) {
 		return []Conflict{
 			{
-				Path:     path,
+				Path:     ctx.path,
 				Expected: y,
 				Actual:   x,
 			},
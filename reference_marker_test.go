@@ -0,0 +1,52 @@
+package matcher
+
+import "testing"
+
+func TestRefMarker(t *testing.T) {
+	j := `{"id": "abc", "echoed_id": "abc", "other": "xyz"}`
+
+	conflicts, err := JSONStringMatches(j, `{"id": "#string", "echoed_id": "#ref /id", "other": "#string"}`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(conflicts) != 0 {
+		t.Fatalf("expected no conflicts, got %v", conflicts)
+	}
+
+	conflicts, err = JSONStringMatches(j, `{"id": "#string", "echoed_id": "#ref /other", "other": "#string"}`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(conflicts) != 1 {
+		t.Fatalf("expected exactly one conflict, got %v", conflicts)
+	}
+}
+
+func TestNeqMarker(t *testing.T) {
+	j := `{"token": "new-token", "previous": {"token": "old-token"}}`
+	conflicts, err := JSONStringMatches(j, `{"token": "#neq /previous/token", "previous": {"token": "#string"}}`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(conflicts) != 0 {
+		t.Fatalf("expected no conflicts, got %v", conflicts)
+	}
+}
+
+func TestEqRelativeSelector(t *testing.T) {
+	j := `{"user": {"id": "u1", "ownerId": "u1"}}`
+	conflicts, err := JSONStringMatches(j, `{"user": {"id": "#string", "ownerId": "#eq @/id"}}`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(conflicts) != 0 {
+		t.Fatalf("expected no conflicts, got %v", conflicts)
+	}
+}
+
+func TestRefMissingSelectorErrors(t *testing.T) {
+	_, err := JSONStringMatches(`{"id": "abc"}`, `{"id": "#ref /missing"}`)
+	if err == nil {
+		t.Fatal("expected an error for an unresolvable selector")
+	}
+}
@@ -0,0 +1,246 @@
+package matcher
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Conflicts is the result of a match: a flat list of every path at which the
+// actual document disagreed with the pattern. It supports rendering itself
+// as a human-readable or machine-readable diff via Report/ReportJSON.
+type Conflicts []Conflict
+
+// ReportOptions configures Conflicts.Report.
+type ReportOptions struct {
+	// WithColor wraps the expected/actual lines in ANSI color codes.
+	WithColor bool
+	// MaxContext caps how many unchanged sibling lines are printed per
+	// group before the rest are elided with a "... N more unchanged" line.
+	// Zero means unlimited.
+	MaxContext int
+}
+
+const (
+	ansiRed   = "\x1b[31m"
+	ansiGreen = "\x1b[32m"
+	ansiReset = "\x1b[0m"
+)
+
+// conflictGroup is every conflict sharing the same immediate parent path,
+// e.g. "/a/b[0]" and "/a/b[2]" both group under "/a/b".
+type conflictGroup struct {
+	prefix    string
+	conflicts []Conflict
+}
+
+// Report renders the conflicts as a unified diff grouped by common path
+// prefix: every conflict whose immediate parent is the same container is
+// printed under one header, each with its own "- expected"/"+ actual" lines,
+// followed by up to MaxContext unchanged sibling values from that container
+// (walked from the original actual document, via Conflict.root) for context.
+func (c Conflicts) Report(options ReportOptions) string {
+	if len(c) == 0 {
+		return ""
+	}
+
+	var groups []conflictGroup
+	groupIndex := make(map[string]int, len(c))
+	for _, conflict := range c {
+		prefix, _ := _splitConflictPath(conflict.Path)
+		idx, ok := groupIndex[prefix]
+		if !ok {
+			idx = len(groups)
+			groupIndex[prefix] = idx
+			groups = append(groups, conflictGroup{prefix: prefix})
+		}
+		groups[idx].conflicts = append(groups[idx].conflicts, conflict)
+	}
+
+	var b strings.Builder
+	for _, group := range groups {
+		_writeConflictGroup(&b, group, options)
+	}
+	return b.String()
+}
+
+func _writeConflictGroup(b *strings.Builder, group conflictGroup, options ReportOptions) {
+	if group.prefix != "" {
+		fmt.Fprintf(b, "%s\n", group.prefix)
+	}
+	labels := make(map[string]bool, len(group.conflicts))
+	for _, conflict := range group.conflicts {
+		_, label := _splitConflictPath(conflict.Path)
+		labels[label] = true
+		fmt.Fprintf(b, "  %s\n", conflict.Path)
+		_writeConflict(b, conflict, options.WithColor)
+	}
+	_writeUnchangedContext(b, group, labels, options.MaxContext)
+}
+
+func _writeConflict(b *strings.Builder, conflict Conflict, withColor bool) {
+	expected := fmt.Sprintf("- expected: %v", conflict.Expected)
+	actual := fmt.Sprintf("+ actual:   %v", conflict.Actual)
+	if conflict.Error != nil {
+		actual = fmt.Sprintf("+ error:    %v", conflict.Error)
+	}
+	if withColor {
+		expected = ansiRed + expected + ansiReset
+		actual = ansiGreen + actual + ansiReset
+	}
+	fmt.Fprintf(b, "    %s\n", expected)
+	fmt.Fprintf(b, "    %s\n", actual)
+}
+
+// _writeUnchangedContext prints the sibling keys/indices of group's parent
+// container that aren't part of the conflict itself, as unchanged context —
+// resolved by walking group.conflicts[0].root, not by re-running the match.
+func _writeUnchangedContext(b *strings.Builder, group conflictGroup, labels map[string]bool, maxContext int) {
+	if len(group.conflicts) == 0 || group.conflicts[0].root == nil {
+		return
+	}
+	container, ok := _resolveConflictPrefix(group.conflicts[0].root, group.prefix)
+	if !ok {
+		return
+	}
+
+	var siblings []string
+	switch v := container.(type) {
+	case map[string]interface{}:
+		for key, val := range v {
+			if labels[key] {
+				continue
+			}
+			siblings = append(siblings, fmt.Sprintf("%s: %v", key, val))
+		}
+	case []interface{}:
+		for i, val := range v {
+			label := fmt.Sprintf("[%d]", i)
+			if labels[label] {
+				continue
+			}
+			siblings = append(siblings, fmt.Sprintf("%s: %v", label, val))
+		}
+	default:
+		return
+	}
+	if len(siblings) == 0 {
+		return
+	}
+	sort.Strings(siblings)
+
+	limit := len(siblings)
+	if maxContext > 0 && maxContext < limit {
+		limit = maxContext
+	}
+	for _, s := range siblings[:limit] {
+		fmt.Fprintf(b, "    %s (unchanged)\n", s)
+	}
+	if limit < len(siblings) {
+		fmt.Fprintf(b, "    ... %d more unchanged\n", len(siblings)-limit)
+	}
+}
+
+// _splitConflictPath splits a conflict path into its immediate parent prefix
+// and its own last segment, e.g. "/a/b" -> ("/a", "b") and "/a/b[2]" ->
+// ("/a/b", "[2]"). The root path "/" has no parent and returns ("", "").
+func _splitConflictPath(path string) (prefix string, label string) {
+	if path == "/" {
+		return "", ""
+	}
+	if strings.HasSuffix(path, "]") {
+		if idx := strings.LastIndex(path, "["); idx != -1 {
+			return path[:idx], path[idx:]
+		}
+	}
+	if idx := strings.LastIndex(path, "/"); idx != -1 {
+		prefix := path[:idx]
+		if prefix == "" {
+			prefix = "/"
+		}
+		return prefix, path[idx+1:]
+	}
+	return "", path
+}
+
+// _resolveConflictPrefix resolves a conflict-path prefix (e.g. "/a/b[0]",
+// using this package's own path notation rather than RFC 6901) against root.
+func _resolveConflictPrefix(root interface{}, prefix string) (interface{}, bool) {
+	if prefix == "" || prefix == "/" {
+		return root, true
+	}
+	cur := root
+	for _, segment := range strings.Split(strings.TrimPrefix(prefix, "/"), "/") {
+		for _, token := range _splitPathSegment(segment) {
+			switch v := cur.(type) {
+			case map[string]interface{}:
+				val, ok := v[token]
+				if !ok {
+					return nil, false
+				}
+				cur = val
+			case []interface{}:
+				idx, err := strconv.Atoi(token)
+				if err != nil || idx < 0 || idx >= len(v) {
+					return nil, false
+				}
+				cur = v[idx]
+			default:
+				return nil, false
+			}
+		}
+	}
+	return cur, true
+}
+
+// _splitPathSegment splits one "/"-delimited path segment, e.g. "items[0][1]"
+// -> ["items", "0", "1"].
+func _splitPathSegment(segment string) []string {
+	var tokens []string
+	for {
+		idx := strings.IndexByte(segment, '[')
+		if idx == -1 {
+			if segment != "" {
+				tokens = append(tokens, segment)
+			}
+			return tokens
+		}
+		if idx > 0 {
+			tokens = append(tokens, segment[:idx])
+		}
+		end := strings.IndexByte(segment[idx:], ']')
+		if end == -1 {
+			return tokens
+		}
+		tokens = append(tokens, segment[idx+1:idx+end])
+		segment = segment[idx+end+1:]
+	}
+}
+
+// reportJSONEntry is the per-path payload of Conflicts.ReportJSON.
+type reportJSONEntry struct {
+	Expected interface{} `json:"expected,omitempty"`
+	Actual   interface{} `json:"actual,omitempty"`
+	Marker   string      `json:"marker,omitempty"`
+}
+
+// ReportJSON renders the conflicts as a machine-readable tree
+// {path: {expected, actual, marker?}}, suitable for consumption by test
+// frameworks.
+func (c Conflicts) ReportJSON() ([]byte, error) {
+	tree := make(map[string]reportJSONEntry, len(c))
+	for _, conflict := range c {
+		entry := reportJSONEntry{Expected: conflict.Expected, Actual: conflict.Actual}
+		if isMarker, marker := getMarker(conflict.Expected); isMarker {
+			entry.Marker = marker
+		}
+		tree[conflict.Path] = entry
+	}
+	data, err := json.Marshal(tree)
+	if err != nil {
+		return nil, fmt.Errorf("can't marshal conflict report: %w", err)
+	}
+	return data, nil
+}
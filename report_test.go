@@ -0,0 +1,84 @@
+package matcher
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReportIncludesPathPerConflict(t *testing.T) {
+	conflicts, err := JSONStringMatches(`{"b": {"c": 2, "d": 3}}`, `{"b": {"c": 99, "d": 88}}`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(conflicts) != 2 {
+		t.Fatalf("expected exactly two conflicts, got %v", conflicts)
+	}
+
+	report := conflicts.Report(ReportOptions{})
+	if !strings.Contains(report, "/b/c") || !strings.Contains(report, "/b/d") {
+		t.Fatalf("expected report to mention both conflicting paths, got:\n%s", report)
+	}
+}
+
+func TestReportGroupsSiblingConflictsWithUnchangedContext(t *testing.T) {
+	conflicts, err := JSONStringMatches(
+		`{"a": {"x": 1, "y": 2, "z": 3}, "b": 4}`,
+		`{"a": {"x": 99, "y": 2, "z": 3}, "b": 4}`,
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(conflicts) != 1 {
+		t.Fatalf("expected exactly one conflict, got %v", conflicts)
+	}
+
+	report := conflicts.Report(ReportOptions{})
+	if !strings.Contains(report, "/a\n") {
+		t.Fatalf("expected report to group under the common prefix /a, got:\n%s", report)
+	}
+	if !strings.Contains(report, "/a/x") {
+		t.Fatalf("expected report to keep the conflict's own path, got:\n%s", report)
+	}
+	if !strings.Contains(report, "y: 2 (unchanged)") || !strings.Contains(report, "z: 3 (unchanged)") {
+		t.Fatalf("expected report to list unchanged siblings as context, got:\n%s", report)
+	}
+}
+
+func TestReportMaxContextLimitsUnchangedSiblings(t *testing.T) {
+	conflicts, err := JSONStringMatches(
+		`{"a": {"x": 1, "y": 2, "z": 3}, "b": 4}`,
+		`{"a": {"x": 99, "y": 2, "z": 3}, "b": 4}`,
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	report := conflicts.Report(ReportOptions{MaxContext: 1})
+	if !strings.Contains(report, "... 1 more unchanged") {
+		t.Fatalf("expected report to elide the remaining unchanged siblings, got:\n%s", report)
+	}
+}
+
+func TestReportJSON(t *testing.T) {
+	conflicts, err := JSONStringMatches(`{"id": 1}`, `{"id": "#string"}`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	data, err := conflicts.ReportJSON()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(string(data), `"/id"`) {
+		t.Fatalf("expected report JSON to key by path, got: %s", data)
+	}
+	if !strings.Contains(string(data), `"#string"`) {
+		t.Fatalf("expected report JSON to include the marker, got: %s", data)
+	}
+}
+
+func TestReportEmptyConflicts(t *testing.T) {
+	var conflicts Conflicts
+	if got := conflicts.Report(ReportOptions{}); got != "" {
+		t.Fatalf("expected an empty report for no conflicts, got %q", got)
+	}
+}